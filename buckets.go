@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gorilla/mux"
+)
+
+// apiError is the structured JSON body returned by the bucket and object
+// admin endpoints when a request fails.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func writeJSONError(writer http.ResponseWriter, status int, err error) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(apiError{Error: err.Error()})
+}
+
+func writeJSON(writer http.ResponseWriter, v interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(v)
+}
+
+// s3Client returns the app's underlying S3 client, since bucket management,
+// policies, and ACLs are S3-specific concepts with no equivalent in the
+// local or GCS providers.
+func (app *App) s3Client() (*s3.S3, bool) {
+	s3st, ok := app.storage.(*s3Storage)
+	if !ok {
+		return nil, false
+	}
+
+	return s3st.client, true
+}
+
+var errBucketAdminRequiresS3 = errNotSupported("bucket management is only supported with the s3 storage provider")
+
+type errNotSupported string
+
+func (e errNotSupported) Error() string { return string(e) }
+
+// listBuckets handles GET /buckets.
+func (app *App) listBuckets(writer http.ResponseWriter, request *http.Request) {
+	client, ok := app.s3Client()
+	if !ok {
+		writeJSONError(writer, http.StatusBadRequest, errBucketAdminRequiresS3)
+		return
+	}
+
+	out, err := client.ListBuckets(&s3.ListBucketsInput{})
+	if err != nil {
+		writeJSONError(writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	names := []string{}
+	for _, bucket := range out.Buckets {
+		names = append(names, aws.StringValue(bucket.Name))
+	}
+
+	writeJSON(writer, names)
+}
+
+// createBucket handles POST /buckets.
+func (app *App) createBucket(writer http.ResponseWriter, request *http.Request) {
+	client, ok := app.s3Client()
+	if !ok {
+		writeJSONError(writer, http.StatusBadRequest, errBucketAdminRequiresS3)
+		return
+	}
+
+	request.ParseForm()
+	name := request.FormValue("name")
+
+	input := &s3.CreateBucketInput{Bucket: aws.String(name)}
+	if lc := request.FormValue("locationConstraint"); lc != "" {
+		input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{LocationConstraint: aws.String(lc)}
+	}
+
+	if _, err := client.CreateBucket(input); err != nil {
+		writeJSONError(writer, http.StatusBadRequest, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusCreated)
+}
+
+// deleteBucket handles DELETE /buckets/{name}.
+func (app *App) deleteBucket(writer http.ResponseWriter, request *http.Request) {
+	client, ok := app.s3Client()
+	if !ok {
+		writeJSONError(writer, http.StatusBadRequest, errBucketAdminRequiresS3)
+		return
+	}
+
+	name := mux.Vars(request)["name"]
+	if _, err := client.DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String(name)}); err != nil {
+		writeJSONError(writer, http.StatusBadRequest, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// getBucketPolicy handles GET /buckets/{name}/policy.
+func (app *App) getBucketPolicy(writer http.ResponseWriter, request *http.Request) {
+	client, ok := app.s3Client()
+	if !ok {
+		writeJSONError(writer, http.StatusBadRequest, errBucketAdminRequiresS3)
+		return
+	}
+
+	name := mux.Vars(request)["name"]
+	out, err := client.GetBucketPolicy(&s3.GetBucketPolicyInput{Bucket: aws.String(name)})
+	if err != nil {
+		writeJSONError(writer, http.StatusBadRequest, err)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write([]byte(aws.StringValue(out.Policy)))
+}
+
+// putBucketPolicy handles PUT /buckets/{name}/policy. The request body is
+// the raw policy document JSON.
+func (app *App) putBucketPolicy(writer http.ResponseWriter, request *http.Request) {
+	client, ok := app.s3Client()
+	if !ok {
+		writeJSONError(writer, http.StatusBadRequest, errBucketAdminRequiresS3)
+		return
+	}
+
+	policy, err := io.ReadAll(request.Body)
+	if err != nil {
+		writeJSONError(writer, http.StatusBadRequest, err)
+		return
+	}
+
+	name := mux.Vars(request)["name"]
+	_, err = client.PutBucketPolicy(&s3.PutBucketPolicyInput{
+		Bucket: aws.String(name),
+		Policy: aws.String(string(policy)),
+	})
+	if err != nil {
+		writeJSONError(writer, http.StatusBadRequest, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// getBucketACL handles GET /buckets/{name}/acl.
+func (app *App) getBucketACL(writer http.ResponseWriter, request *http.Request) {
+	client, ok := app.s3Client()
+	if !ok {
+		writeJSONError(writer, http.StatusBadRequest, errBucketAdminRequiresS3)
+		return
+	}
+
+	name := mux.Vars(request)["name"]
+	out, err := client.GetBucketAcl(&s3.GetBucketAclInput{Bucket: aws.String(name)})
+	if err != nil {
+		writeJSONError(writer, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(writer, out)
+}
+
+// putBucketACL handles PUT /buckets/{name}/acl, setting a canned ACL (e.g.
+// "private", "public-read") passed in the "acl" form field.
+func (app *App) putBucketACL(writer http.ResponseWriter, request *http.Request) {
+	client, ok := app.s3Client()
+	if !ok {
+		writeJSONError(writer, http.StatusBadRequest, errBucketAdminRequiresS3)
+		return
+	}
+
+	request.ParseForm()
+	name := mux.Vars(request)["name"]
+	_, err := client.PutBucketAcl(&s3.PutBucketAclInput{
+		Bucket: aws.String(name),
+		ACL:    aws.String(request.FormValue("acl")),
+	})
+	if err != nil {
+		writeJSONError(writer, http.StatusBadRequest, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// parseDeleteForm populates request.Form from a form-urlencoded body. It
+// exists because net/http's ParseForm only reads the body for POST, PUT, and
+// PATCH requests, but deleteObject follows the same form-body convention as
+// the rest of the admin API on a DELETE request.
+func parseDeleteForm(request *http.Request) error {
+	if request.Form == nil {
+		request.Form = url.Values{}
+	}
+	if request.Body == nil {
+		return nil
+	}
+
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		return err
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return err
+	}
+	for key, vals := range values {
+		request.Form[key] = append(request.Form[key], vals...)
+	}
+
+	return nil
+}
+
+// deleteObject handles DELETE /object, removing a single object (the "key"
+// form field) or a batch of them (repeated "key" fields). bucket and key may
+// be passed as query params or as a form-urlencoded body.
+func (app *App) deleteObject(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	if err := parseDeleteForm(request); err != nil {
+		writeJSONError(writer, http.StatusBadRequest, err)
+		return
+	}
+
+	bucket := request.FormValue("bucket")
+	keys := request.Form["key"]
+
+	if len(keys) == 0 {
+		writeJSONError(writer, http.StatusBadRequest, errNotSupported("at least one key must be provided"))
+		return
+	}
+
+	var err error
+	if len(keys) == 1 {
+		err = app.storage.Delete(bucket, keys[0])
+	} else {
+		err = app.storage.DeleteMany(bucket, keys)
+	}
+	if err != nil {
+		writeJSONError(writer, http.StatusBadRequest, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/dutchcoders/go-clamd"
+)
+
+// virusScanner streams uploaded file data to a clamd daemon before it is
+// committed to object storage, so public drop-box style deployments can
+// reject malware without ever having stored it.
+type virusScanner struct {
+	client *clamd.Clamd
+}
+
+// newVirusScanner builds a virusScanner from the CLAMD_ADDR env var (e.g.
+// "tcp://localhost:3310"). It returns nil, meaning scanning is disabled, if
+// CLAMD_ADDR is not set.
+func newVirusScanner() *virusScanner {
+	addr := os.Getenv("CLAMD_ADDR")
+	if addr == "" {
+		return nil
+	}
+
+	return &virusScanner{client: clamd.NewClamd(addr)}
+}
+
+// ScanReader streams reader to clamd to completion and returns the final
+// verdict. Callers must wait for it to return before acting on reader's
+// data (e.g. uploading it), since the verdict isn't known until the scan
+// finishes.
+func (s *virusScanner) ScanReader(reader io.Reader) (*clamd.ScanResult, error) {
+	results, err := s.client.ScanStream(reader, make(chan bool))
+	if err != nil {
+		return nil, err
+	}
+
+	var last *clamd.ScanResult
+	for result := range results {
+		last = result
+	}
+
+	return last, nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// downloadFileFromBucket streams an object to the client, honouring Range
+// and If-None-Match so large objects never need to be held in memory.
+func (app *App) downloadFileFromBucket(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+
+	var (
+		bucket       = request.FormValue("bucket")
+		downloadFile = request.FormValue("downloadFile")
+		file         = request.FormValue("file")
+	)
+
+	fmt.Printf("Attempting to download %s from bucket: %s\n", file, bucket)
+
+	info, err := app.storage.Head(bucket, file)
+	if err != nil {
+		writer.WriteHeader(400)
+		writer.Write([]byte(fmt.Sprintf("could not retrieve file metadata: %v", err)))
+		return
+	}
+
+	if !info.LastModified.IsZero() {
+		writer.Header().Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if info.ETag != "" {
+		writer.Header().Set("ETag", info.ETag)
+		if request.Header.Get("If-None-Match") == info.ETag {
+			writer.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	if downloadFile == "yes" {
+		app.downloadFileToDisk(writer, bucket, file)
+		return
+	}
+
+	rangeHeader := request.Header.Get("Range")
+	if rangeHeader != "" {
+		app.downloadRange(writer, bucket, file, rangeHeader, info)
+		return
+	}
+
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	writer.Header().Set("Accept-Ranges", "bytes")
+	writer.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	writer.Header().Set("Content-Type", contentType)
+	cd := mime.FormatMediaType("attachment", map[string]string{"filename": file})
+	writer.Header().Set("Content-Disposition", cd)
+
+	n, err := app.storage.GetWriterAt(bucket, file, &responseWriterAt{w: writer})
+	if err != nil {
+		fmt.Printf("Could not download file. Reason: %v.\n", err)
+		return
+	}
+	fmt.Printf("Downloaded file. Size: %d\n", n)
+}
+
+func (app *App) downloadRange(writer http.ResponseWriter, bucket, file, rangeHeader string, info ObjectInfo) {
+	reader, rangeInfo, err := app.storage.Get(bucket, file, rangeHeader)
+	if err != nil {
+		writer.WriteHeader(400)
+		writer.Write([]byte(fmt.Sprintf("could not download file: %v", err)))
+		return
+	}
+	defer reader.Close()
+
+	offset, length, err := parseByteRange(rangeHeader, info.Size)
+	if err != nil {
+		writer.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		writer.Write([]byte(fmt.Sprintf("invalid range: %v", err)))
+		return
+	}
+
+	contentType := rangeInfo.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	writer.Header().Set("Accept-Ranges", "bytes")
+	writer.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, info.Size))
+	writer.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	writer.Header().Set("Content-Type", contentType)
+	writer.WriteHeader(http.StatusPartialContent)
+
+	if _, err := io.Copy(writer, reader); err != nil {
+		fmt.Printf("Could not stream range. Reason: %v.\n", err)
+	}
+}
+
+// downloadFileToDisk writes bucket/file to disk under the server's working
+// directory. file is client-supplied, so it is resolved the same way
+// localStorage resolves bucket/key, rejecting ".." segments that would
+// otherwise let a caller write outside that directory.
+func (app *App) downloadFileToDisk(writer http.ResponseWriter, bucket, file string) {
+	reader, _, err := app.storage.Get(bucket, file, "")
+	if err != nil {
+		writer.WriteHeader(400)
+		writer.Write([]byte(fmt.Sprintf("could not download file: %v", err)))
+		return
+	}
+	defer reader.Close()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Could not resolve download path for %s. Reason: %s", file, err)
+		return
+	}
+
+	path, err := resolve(wd, filepath.FromSlash(file))
+	if err != nil {
+		fmt.Printf("Could not write file to %s\n. Reason: %s", file, err)
+		return
+	}
+
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o755)
+	if err != nil {
+		fmt.Printf("Could not write file to %s\n. Reason: %s", path, err)
+		return
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, reader); err != nil {
+		fmt.Printf("Could not write file to %s\n. Reason: %s", path, err)
+		return
+	}
+	fmt.Printf("Wrote file to %s\n", path)
+}
+
+// responseWriterAt adapts an http.ResponseWriter to io.WriterAt so it can be
+// used as the destination of a concurrent, multi-part download. Chunks are
+// written to the client in order as they arrive; chunks that arrive ahead of
+// the current offset are buffered until the gap before them is filled.
+type responseWriterAt struct {
+	w    io.Writer
+	mu   sync.Mutex
+	next int64
+	held map[int64][]byte
+}
+
+func (r *responseWriterAt) WriteAt(p []byte, offset int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.held == nil {
+		r.held = map[int64][]byte{}
+	}
+
+	if offset != r.next {
+		buf := make([]byte, len(p))
+		copy(buf, p)
+		r.held[offset] = buf
+		return len(p), nil
+	}
+
+	if err := r.flush(p); err != nil {
+		return 0, err
+	}
+
+	for {
+		chunk, ok := r.held[r.next]
+		if !ok {
+			break
+		}
+		delete(r.held, r.next)
+		if err := r.flush(chunk); err != nil {
+			return len(p), err
+		}
+	}
+
+	return len(p), nil
+}
+
+func (r *responseWriterAt) flush(p []byte) error {
+	if _, err := r.w.Write(p); err != nil {
+		return err
+	}
+	r.next += int64(len(p))
+
+	return nil
+}
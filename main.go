@@ -1,24 +1,21 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
-	"io/fs"
 	"log"
-	"mime"
-	"mime/multipart"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/dutchcoders/go-clamd"
+	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 )
 
@@ -28,36 +25,38 @@ type s3Data struct {
 }
 
 type App struct {
-	s3Client *s3.S3
-	session *session.Session
+	storage Storage
+	scanner *virusScanner
 }
 
-func newApp() App {
-	sess := session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
-	
-	return App{s3Client: s3.New(sess), session: sess} 
-}
+// newApp builds the App, selecting its Storage backend from the -provider
+// flag (falling back to the STORAGE_PROVIDER env var, then the s3 provider).
+// -basedir (or BASEDIR) configures the local provider's root directory. If
+// CLAMD_ADDR is set, uploads are scanned for malware as they stream through.
+func newApp() (App, error) {
+	provider := flag.String("provider", os.Getenv("STORAGE_PROVIDER"), "storage backend to use: s3, local, or gcs")
+	basedir := flag.String("basedir", os.Getenv("BASEDIR"), "root directory for the local storage provider")
+	flag.Parse()
 
-func uploadFile(file multipart.File) ([]byte, error) {
-	buf := bytes.NewBuffer(nil)
-	if _, err := io.Copy(buf, file); err != nil {
-		return nil, fmt.Errorf("could not upload file. %v", err)
+	storage, err := newStorage(storageProvider(*provider), *basedir)
+	if err != nil {
+		return App{}, fmt.Errorf("could not initialise storage provider: %v", err)
 	}
 
-	return buf.Bytes(), nil
+	return App{storage: storage, scanner: newVirusScanner()}, nil
 }
 
-// listFilesInBucket retrieves and returns all of the files in an S3(-compatible) Bucket
-func (app *App)  listFilesInBucket(writer http.ResponseWriter, request *http.Request) {
+// listFilesInBucket retrieves and returns all of the files in a bucket. The
+// listing is bounded by the DURATION env var, so a slow or unresponsive
+// backend can't hang the request indefinitely.
+func (app *App) listFilesInBucket(writer http.ResponseWriter, request *http.Request) {
 	request.ParseForm()
 	var bucket string = request.FormValue("bucket")
 
 	duration, exists := os.LookupEnv("DURATION")
 	if !exists {
 		writer.WriteHeader(400)
-		writer.Write([]byte("could not retrieve duration, %v"))
+		writer.Write([]byte("could not retrieve duration"))
 		return
 	}
 
@@ -67,26 +66,15 @@ func (app *App)  listFilesInBucket(writer http.ResponseWriter, request *http.Req
 		writer.Write([]byte(fmt.Sprintf("could not parse provided duration, %v", err)))
 		return
 	}
-	ctx := context.Background()
-	var cancelFn func()
+
+	ctx := request.Context()
 	if timeout > 0 {
-		ctx, cancelFn = context.WithTimeout(ctx, timeout)
-	}
-	if cancelFn != nil {
-		defer cancelFn()
-	}
-
-	objects := []s3Data{}
-	err = app.s3Client.ListObjectsPagesWithContext(
-		ctx,
-		&s3.ListObjectsInput{Bucket: aws.String(bucket)},
-		func(p *s3.ListObjectsOutput, lastPage bool) bool {
-			for _, o := range p.Contents {
-				objects = append(objects, s3Data{Key: aws.StringValue(o.Key), Size: *aws.Int64(*o.Size)})
-			}
-			return true
-		},
-	)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	objects, err := app.storage.List(ctx, bucket)
 	if err != nil {
 		writer.WriteHeader(400)
 		writer.Write([]byte(fmt.Sprintf("failed to list objects for bucket, %s, %v", bucket, err)))
@@ -97,101 +85,115 @@ func (app *App)  listFilesInBucket(writer http.ResponseWriter, request *http.Req
 	fmt.Printf("successfully retrieved files from bucket: %s.\n", bucket)
 }
 
-func (app *App) uploadFileToBucket(writer http.ResponseWriter, request *http.Request) {
-	request.ParseForm()
-
-	// Create an uploader with the session and default options
-	uploader := s3manager.NewUploader(app.session)
+// metaFieldPrefix marks a form field as arbitrary object metadata, e.g. a
+// field named "meta-author" is forwarded as the "author" metadata key.
+const metaFieldPrefix = "meta-"
 
+func (app *App) uploadFileToBucket(writer http.ResponseWriter, request *http.Request) {
 	file, fileMetadata, err := request.FormFile("file")
 	if err != nil {
 		writer.WriteHeader(400)
 		writer.Write([]byte(fmt.Sprintf("could not get file data from request: %v", err)))
 		return
 	}
+	defer file.Close()
 
-	fileData, err := uploadFile(file)
+	var bucket string = request.FormValue("bucket")
+
+	// The upload is always spooled to a temporary file first, rather than
+	// streamed straight into the bucket. That way, if a virus scanner is
+	// configured, scanning can run to completion - and reject the file -
+	// before anything is ever committed to storage.
+	spool, err := os.CreateTemp("", "upload-*")
 	if err != nil {
-		writer.WriteHeader(400)
-		writer.Write([]byte(fmt.Sprintf("could not upload file: %v", err)))
+		writer.WriteHeader(500)
+		writer.Write([]byte(fmt.Sprintf("could not create upload spool: %v", err)))
 		return
 	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
 
-	var bucket string = request.FormValue("bucket")
-
-	// Upload the file to S3.
-	result, err := uploader.Upload(&s3manager.UploadInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(fileMetadata.Filename),
-		Body:   bytes.NewBuffer(fileData),
-	})
-	if err != nil {
+	hasher := sha256.New()
+	if _, err := io.Copy(spool, io.TeeReader(file, hasher)); err != nil {
 		writer.WriteHeader(400)
-		writer.Write([]byte(fmt.Sprintf("failed to upload file to S3 bucket: %v", err)))
+		writer.Write([]byte(fmt.Sprintf("could not read file data: %v", err)))
 		return
 	}
 
-	fmt.Printf("file uploaded to, %s\n", aws.StringValue(&result.Location))
-	writer.Write([]byte(fmt.Sprintf("file uploaded to S3 bucket: %s", aws.StringValue(&result.Location))))
-}
-
-func (app *App) downloadFileFromBucket(writer http.ResponseWriter, request *http.Request) {
-	request.ParseForm()
-
-	var (
-		bucket = request.FormValue("bucket")
-		downloadFile = request.FormValue("downloadFile")
-		file = request.FormValue("file")
-	)
-
-	fmt.Printf("Attempting to download %s from bucket: %s\n", file, bucket)
-	result, err := app.s3Client.HeadObject(&s3.HeadObjectInput{
-		Bucket: aws.String(bucket),
-		Key: aws.String(file),
-	})
-	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			default:
-				fmt.Println(aerr.Error())
-			}
-		} else {
-			fmt.Println(err.Error())
+	contentType := fileMetadata.Header.Get("Content-Type")
+	if contentType == "" {
+		// io.Copy above left spool's cursor at EOF, so it must be rewound
+		// before detectFileContentType can read the first 512 bytes.
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			writer.WriteHeader(500)
+			writer.Write([]byte(fmt.Sprintf("could not read file data: %v", err)))
+			return
+		}
+		if contentType, err = detectFileContentType(spool); err != nil {
+			writer.WriteHeader(400)
+			writer.Write([]byte(fmt.Sprintf("could not read file data: %v", err)))
+			return
 		}
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		writer.WriteHeader(500)
+		writer.Write([]byte(fmt.Sprintf("could not read file data: %v", err)))
 		return
 	}
-	fmt.Printf("File size is %d.\n", *result.ContentLength)
 
-	buf := make([]byte, *result.ContentLength)
-	// Create an uploader with the session and default options
-	downloader := s3manager.NewDownloader(app.session)
-	input := &s3.GetObjectInput{
-		Bucket: aws.String(bucket),
-		Key: aws.String(file),
-	}
-	objectSize, err := downloader.Download(aws.NewWriteAtBuffer(buf), input)
-	if err != nil {
-		fmt.Printf("Could not download file. Reason: %v.\n", err)
+	metadata := map[string]string{}
+	for field, values := range request.MultipartForm.Value {
+		if len(values) == 0 || !strings.HasPrefix(field, metaFieldPrefix) {
+			continue
+		}
+		metadata[strings.TrimPrefix(field, metaFieldPrefix)] = values[0]
 	}
-	fmt.Printf("Downloaded file. Size: %d\n", objectSize)
 
-	if (downloadFile == "yes") {
-		var fileMode fs.FileMode = 0755
-		err = os.WriteFile(file, buf, fileMode)
+	if app.scanner != nil {
+		result, err := app.scanner.ScanReader(spool)
 		if err != nil {
-			fmt.Printf("Could not write file to %s\n. Reason: %s", file, err)
-		} else {
-			fmt.Printf("Wrote file to %s\n", file)
+			writer.WriteHeader(500)
+			writer.Write([]byte(fmt.Sprintf("could not scan file for viruses: %v", err)))
+			return
+		}
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			writer.WriteHeader(500)
+			writer.Write([]byte(fmt.Sprintf("could not read file data: %v", err)))
+			return
+		}
+		if result != nil && result.Status == clamd.RES_FOUND {
+			writer.WriteHeader(http.StatusUnprocessableEntity)
+			writer.Write([]byte(fmt.Sprintf("upload rejected: %s", result.Description)))
+			return
 		}
+	}
 
+	putOpts := PutOptions{
+		ContentType:          contentType,
+		Size:                 fileMetadata.Size,
+		Metadata:             metadata,
+		ServerSideEncryption: request.FormValue("ServerSideEncryption"),
+		SSEKMSKeyId:          request.FormValue("SSEKMSKeyId"),
+		StorageClass:         request.FormValue("StorageClass"),
+		CacheControl:         request.FormValue("CacheControl"),
+	}
+
+	if err := app.storage.Put(bucket, fileMetadata.Filename, spool, putOpts); err != nil {
+		writer.WriteHeader(400)
+		writer.Write([]byte(fmt.Sprintf("failed to upload file to bucket: %v", err)))
 		return
 	}
 
-	cd := mime.FormatMediaType("attachment", map[string]string{"filename": file})
-	writer.Header().Set("Content-Disposition", cd)
-	writer.Header().Set("Content-Type", http.DetectContentType(buf))
-	io.Copy(writer, bytes.NewBuffer(buf))
-	fmt.Println("Downloaded file.")
+	fingerprint := hex.EncodeToString(hasher.Sum(nil))
+	if s3st, ok := app.storage.(*s3Storage); ok {
+		metadata["fingerprint"] = fingerprint
+		if err := s3st.updateMetadata(bucket, fileMetadata.Filename, metadata, putOpts); err != nil {
+			fmt.Printf("could not attach fingerprint metadata to %s/%s: %v\n", bucket, fileMetadata.Filename, err)
+		}
+	}
+
+	fmt.Printf("file uploaded to, %s/%s (sha256:%s)\n", bucket, fileMetadata.Filename, fingerprint)
+	writer.Write([]byte(fmt.Sprintf("file uploaded to bucket: %s/%s", bucket, fileMetadata.Filename)))
 }
 
 func main() {
@@ -199,16 +201,36 @@ func main() {
 		log.Print("No .env file found")
 	}
 
-	app := newApp()
+	app, err := newApp()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	router := mux.NewRouter()
 
 	// List files in the bucket
-	http.HandleFunc("/", app.listFilesInBucket)
+	router.HandleFunc("/", app.listFilesInBucket).Methods(http.MethodGet)
 
 	// Upload a file to the bucket
-	http.HandleFunc("/upload", app.uploadFileToBucket)
+	router.HandleFunc("/upload", app.uploadFileToBucket).Methods(http.MethodPost)
 
 	// Download a file from the bucket
-	http.HandleFunc("/download", app.downloadFileFromBucket)
-
-	http.ListenAndServe(":8080", nil)
+	router.HandleFunc("/download", app.downloadFileFromBucket).Methods(http.MethodGet)
+
+	// Presigned URLs for direct client upload/download
+	router.HandleFunc("/presign/upload", app.presignUpload).Methods(http.MethodGet)
+	router.HandleFunc("/presign/upload/post", app.presignUploadPost).Methods(http.MethodGet)
+	router.HandleFunc("/presign/download", app.presignDownload).Methods(http.MethodGet)
+
+	// Bucket and object administration
+	router.HandleFunc("/buckets", app.listBuckets).Methods(http.MethodGet)
+	router.HandleFunc("/buckets", app.createBucket).Methods(http.MethodPost)
+	router.HandleFunc("/buckets/{name}", app.deleteBucket).Methods(http.MethodDelete)
+	router.HandleFunc("/buckets/{name}/policy", app.getBucketPolicy).Methods(http.MethodGet)
+	router.HandleFunc("/buckets/{name}/policy", app.putBucketPolicy).Methods(http.MethodPut)
+	router.HandleFunc("/buckets/{name}/acl", app.getBucketACL).Methods(http.MethodGet)
+	router.HandleFunc("/buckets/{name}/acl", app.putBucketACL).Methods(http.MethodPut)
+	router.HandleFunc("/object", app.deleteObject).Methods(http.MethodDelete)
+
+	http.ListenAndServe(":8080", router)
 }
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// presignedURL is the JSON payload returned by the presign endpoints, giving
+// a client everything it needs to talk to the storage backend directly.
+type presignedURL struct {
+	URL       string            `json:"url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+// presignedPostPolicy is the JSON payload returned by presignUploadPost: a
+// URL and the form fields (including the policy signature) an HTML form
+// needs to POST a file directly to the bucket.
+type presignedPostPolicy struct {
+	URL    string            `json:"url"`
+	Fields map[string]string `json:"fields"`
+}
+
+// presignDuration reads the DURATION env var used elsewhere in the app and
+// parses it into the lifetime of a presigned URL.
+func presignDuration() (time.Duration, error) {
+	duration, exists := os.LookupEnv("DURATION")
+	if !exists {
+		return 0, fmt.Errorf("could not retrieve duration")
+	}
+
+	return time.ParseDuration(duration)
+}
+
+// presignUpload issues a time-limited URL that a client can PUT a file to
+// directly, bypassing the server entirely.
+func (app *App) presignUpload(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	var (
+		bucket = request.FormValue("bucket")
+		key    = request.FormValue("file")
+	)
+
+	s3st, ok := app.storage.(*s3Storage)
+	if !ok {
+		writer.WriteHeader(400)
+		writer.Write([]byte("presigned URLs are only supported with the s3 storage provider"))
+		return
+	}
+
+	timeout, err := presignDuration()
+	if err != nil {
+		writer.WriteHeader(400)
+		writer.Write([]byte(fmt.Sprintf("could not parse provided duration, %v", err)))
+		return
+	}
+
+	req, _ := s3st.client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+
+	url, headers, err := req.PresignRequest(timeout)
+	if err != nil {
+		writer.WriteHeader(400)
+		writer.Write([]byte(fmt.Sprintf("could not presign upload request: %v", err)))
+		return
+	}
+
+	writeJSONPresignedURL(writer, url, http.MethodPut, headers, timeout)
+}
+
+// presignUploadPost issues a time-limited POST policy that an HTML form can
+// submit a file to directly, for browsers that can't issue a PUT request.
+func (app *App) presignUploadPost(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	var (
+		bucket = request.FormValue("bucket")
+		key    = request.FormValue("file")
+	)
+
+	s3st, ok := app.storage.(*s3Storage)
+	if !ok {
+		writer.WriteHeader(400)
+		writer.Write([]byte("presigned URLs are only supported with the s3 storage provider"))
+		return
+	}
+
+	timeout, err := presignDuration()
+	if err != nil {
+		writer.WriteHeader(400)
+		writer.Write([]byte(fmt.Sprintf("could not parse provided duration, %v", err)))
+		return
+	}
+
+	policy, err := s3st.presignPostPolicy(bucket, key, timeout)
+	if err != nil {
+		writer.WriteHeader(400)
+		writer.Write([]byte(fmt.Sprintf("could not presign upload policy: %v", err)))
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(policy)
+}
+
+// presignDownload issues a time-limited URL that a client can GET a file
+// from directly, bypassing the server entirely.
+func (app *App) presignDownload(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	var (
+		bucket = request.FormValue("bucket")
+		key    = request.FormValue("file")
+	)
+
+	s3st, ok := app.storage.(*s3Storage)
+	if !ok {
+		writer.WriteHeader(400)
+		writer.Write([]byte("presigned URLs are only supported with the s3 storage provider"))
+		return
+	}
+
+	timeout, err := presignDuration()
+	if err != nil {
+		writer.WriteHeader(400)
+		writer.Write([]byte(fmt.Sprintf("could not parse provided duration, %v", err)))
+		return
+	}
+
+	req, _ := s3st.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+
+	url, headers, err := req.PresignRequest(timeout)
+	if err != nil {
+		writer.WriteHeader(400)
+		writer.Write([]byte(fmt.Sprintf("could not presign download request: %v", err)))
+		return
+	}
+
+	writeJSONPresignedURL(writer, url, http.MethodGet, headers, timeout)
+}
+
+func writeJSONPresignedURL(writer http.ResponseWriter, url, method string, headers http.Header, timeout time.Duration) {
+	h := map[string]string{}
+	for name := range headers {
+		h[name] = headers.Get(name)
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(presignedURL{
+		URL:       url,
+		Method:    method,
+		Headers:   h,
+		ExpiresAt: time.Now().Add(timeout),
+	})
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Storage abstracts the object storage backend used by App so that the HTTP
+// handlers can run against S3-compatible services, a local directory, or
+// Google Cloud Storage without any code changes.
+type Storage interface {
+	// Get returns a reader for bucket/key, along with its metadata. If
+	// rangeHeader is non-empty, it is the raw HTTP Range header value and
+	// only the requested byte range is returned. The caller is responsible
+	// for closing the returned reader.
+	Get(bucket, key, rangeHeader string) (io.ReadCloser, ObjectInfo, error)
+	// Head returns metadata for bucket/key without fetching its contents.
+	Head(bucket, key string) (ObjectInfo, error)
+	// GetWriterAt writes the full contents of bucket/key to w, returning the
+	// number of bytes written. Providers that support it (currently S3) may
+	// fetch multiple parts concurrently.
+	GetWriterAt(bucket, key string, w io.WriterAt) (int64, error)
+	// Put streams reader to bucket/key per the given options.
+	Put(bucket, key string, reader io.Reader, opts PutOptions) error
+	// List returns metadata for every object in bucket. ctx bounds how long
+	// the listing may run, e.g. via the DURATION-derived timeout in
+	// listFilesInBucket.
+	List(ctx context.Context, bucket string) ([]s3Data, error)
+	// Delete removes a single object.
+	Delete(bucket, key string) error
+	// DeleteMany removes a batch of objects in one call where the provider
+	// supports it.
+	DeleteMany(bucket string, keys []string) error
+}
+
+// ObjectInfo describes an object's metadata, independent of provider.
+type ObjectInfo struct {
+	ContentType  string
+	Size         int64
+	LastModified time.Time
+	ETag         string
+}
+
+// PutOptions controls how Put stores an object. ContentType is the only
+// field every provider is expected to honour; the rest are passed through to
+// providers that support them (currently just S3) and ignored otherwise.
+type PutOptions struct {
+	ContentType          string
+	Size                 int64
+	Metadata             map[string]string
+	ServerSideEncryption string
+	SSEKMSKeyId          string
+	StorageClass         string
+	CacheControl         string
+}
+
+// storageProvider identifies which Storage implementation newStorage should
+// construct.
+type storageProvider string
+
+const (
+	providerS3    storageProvider = "s3"
+	providerLocal storageProvider = "local"
+	providerGCS   storageProvider = "gcs"
+)
+
+// newStorage builds the Storage backend selected by provider. basedir is only
+// used by the local provider, and is the directory under which objects are
+// stored, namespaced by bucket.
+func newStorage(provider storageProvider, basedir string) (Storage, error) {
+	switch provider {
+	case providerS3, "":
+		return newS3Storage(), nil
+	case providerLocal:
+		if basedir == "" {
+			return nil, fmt.Errorf("basedir must be set when using the %q provider", providerLocal)
+		}
+		if err := os.MkdirAll(basedir, 0o755); err != nil {
+			return nil, fmt.Errorf("could not create basedir %s: %v", basedir, err)
+		}
+		return &localStorage{basedir: basedir}, nil
+	case providerGCS:
+		return newGCSStorage()
+	default:
+		return nil, fmt.Errorf("unknown storage provider: %q", provider)
+	}
+}
+
+// parseByteRange parses a single-range HTTP Range header (e.g. "bytes=0-499")
+// against an object of the given size, returning the requested offset and
+// length. Multi-range requests are not supported.
+func parseByteRange(rangeHeader string, size int64) (offset, length int64, err error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(rangeHeader, prefix) {
+		return 0, 0, fmt.Errorf("unsupported range header: %q", rangeHeader)
+	}
+
+	bounds := strings.SplitN(strings.TrimPrefix(rangeHeader, prefix), "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, fmt.Errorf("unsupported range header: %q", rangeHeader)
+	}
+
+	switch {
+	case bounds[0] == "":
+		// Suffix range, e.g. "bytes=-500" means the last 500 bytes.
+		suffixLength, err := strconv.ParseInt(bounds[1], 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		if suffixLength > size {
+			suffixLength = size
+		}
+		return size - suffixLength, suffixLength, nil
+	default:
+		start, err := strconv.ParseInt(bounds[0], 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		if start < 0 || start >= size {
+			return 0, 0, fmt.Errorf("range start %d out of bounds for size %d", start, size)
+		}
+
+		end := size - 1
+		if bounds[1] != "" {
+			end, err = strconv.ParseInt(bounds[1], 10, 64)
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+		if end > size-1 {
+			end = size - 1
+		}
+
+		return start, end - start + 1, nil
+	}
+}
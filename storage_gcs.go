@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// gcsStorage is the Storage implementation backed by a Google Cloud Storage
+// bucket.
+type gcsStorage struct {
+	client *storage.Client
+}
+
+func newGCSStorage() (*gcsStorage, error) {
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return &gcsStorage{client: client}, nil
+}
+
+func (g *gcsStorage) Get(bucket, key, rangeHeader string) (io.ReadCloser, ObjectInfo, error) {
+	ctx := context.Background()
+	obj := g.client.Bucket(bucket).Object(key)
+
+	if rangeHeader == "" {
+		reader, err := obj.NewReader(ctx)
+		if err != nil {
+			return nil, ObjectInfo{}, err
+		}
+
+		return reader, ObjectInfo{ContentType: reader.Attrs.ContentType, Size: reader.Attrs.Size, LastModified: reader.Attrs.LastModified, ETag: fmt.Sprint(reader.Attrs.Generation)}, nil
+	}
+
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	offset, length, err := parseByteRange(rangeHeader, attrs.Size)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	reader, err := obj.NewRangeReader(ctx, offset, length)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	return reader, ObjectInfo{ContentType: attrs.ContentType, Size: length, LastModified: attrs.Updated, ETag: fmt.Sprint(attrs.Generation)}, nil
+}
+
+func (g *gcsStorage) Head(bucket, key string) (ObjectInfo, error) {
+	ctx := context.Background()
+
+	attrs, err := g.client.Bucket(bucket).Object(key).Attrs(ctx)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	return ObjectInfo{ContentType: attrs.ContentType, Size: attrs.Size, LastModified: attrs.Updated, ETag: fmt.Sprint(attrs.Generation)}, nil
+}
+
+// GetWriterAt writes the full contents of bucket/key to w. The GCS provider
+// does not currently split objects into concurrently-fetched parts.
+func (g *gcsStorage) GetWriterAt(bucket, key string, w io.WriterAt) (int64, error) {
+	reader, info, err := g.Get(bucket, key, "")
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.WriteAt(data, 0)
+	if err != nil {
+		return int64(n), err
+	}
+
+	return info.Size, nil
+}
+
+func (g *gcsStorage) Put(bucket, key string, reader io.Reader, opts PutOptions) error {
+	ctx := context.Background()
+
+	writer := g.client.Bucket(bucket).Object(key).NewWriter(ctx)
+	writer.ContentType = opts.ContentType
+	if len(opts.Metadata) > 0 {
+		writer.Metadata = opts.Metadata
+	}
+	if opts.CacheControl != "" {
+		writer.CacheControl = opts.CacheControl
+	}
+	if opts.StorageClass != "" {
+		writer.StorageClass = opts.StorageClass
+	}
+
+	if _, err := io.Copy(writer, reader); err != nil {
+		writer.Close()
+		return err
+	}
+
+	return writer.Close()
+}
+
+func (g *gcsStorage) Delete(bucket, key string) error {
+	return g.client.Bucket(bucket).Object(key).Delete(context.Background())
+}
+
+func (g *gcsStorage) DeleteMany(bucket string, keys []string) error {
+	for _, key := range keys {
+		if err := g.Delete(bucket, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *gcsStorage) List(ctx context.Context, bucket string) ([]s3Data, error) {
+	objects := []s3Data{}
+	it := g.client.Bucket(bucket).Objects(ctx, nil)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, s3Data{Key: attrs.Name, Size: attrs.Size})
+	}
+
+	return objects, nil
+}
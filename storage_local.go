@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localETag derives a weak, cheap-to-compute ETag from an object's size and
+// modification time, since the local provider has no content hash handy.
+func localETag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+// localStorage is a Storage implementation rooted at a directory on disk.
+// Buckets are modelled as subdirectories of basedir, and objects as files
+// within them. It exists mainly so the rest of the application can be
+// exercised in tests or on a laptop without a real S3-compatible endpoint.
+type localStorage struct {
+	basedir string
+}
+
+// resolve joins basedir with parts and verifies the result is still inside
+// basedir, rejecting bucket/key values (both client-controlled) that try to
+// escape it via ".." segments or absolute paths.
+func resolve(basedir string, parts ...string) (string, error) {
+	full := filepath.Join(append([]string{basedir}, parts...)...)
+
+	rel, err := filepath.Rel(basedir, full)
+	if err != nil {
+		return "", fmt.Errorf("invalid path: %v", err)
+	}
+	if rel != "." && (rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))) {
+		return "", fmt.Errorf("path escapes storage root")
+	}
+
+	return full, nil
+}
+
+func (l *localStorage) bucketDir(bucket string) (string, error) {
+	return resolve(l.basedir, bucket)
+}
+
+func (l *localStorage) objectPath(bucket, key string) (string, error) {
+	return resolve(l.basedir, bucket, filepath.FromSlash(key))
+}
+
+func (l *localStorage) Get(bucket, key, rangeHeader string) (io.ReadCloser, ObjectInfo, error) {
+	path, err := l.objectPath(bucket, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, ObjectInfo{}, err
+	}
+
+	contentType, err := detectFileContentType(file)
+	if err != nil {
+		file.Close()
+		return nil, ObjectInfo{}, err
+	}
+
+	info := ObjectInfo{ContentType: contentType, Size: stat.Size(), LastModified: stat.ModTime(), ETag: localETag(stat)}
+
+	if rangeHeader == "" {
+		return file, info, nil
+	}
+
+	offset, length, err := parseByteRange(rangeHeader, info.Size)
+	if err != nil {
+		file.Close()
+		return nil, ObjectInfo{}, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, ObjectInfo{}, err
+	}
+	info.Size = length
+
+	return readCloser{Reader: io.LimitReader(file, length), Closer: file}, info, nil
+}
+
+func (l *localStorage) Head(bucket, key string) (ObjectInfo, error) {
+	file, info, err := l.Get(bucket, key, "")
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	file.Close()
+
+	return info, nil
+}
+
+// GetWriterAt writes the full contents of bucket/key to w. The local
+// provider has no benefit from concurrent part fetching, so it simply
+// streams the file once.
+func (l *localStorage) GetWriterAt(bucket, key string, w io.WriterAt) (int64, error) {
+	file, info, err := l.Get(bucket, key, "")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	buf := make([]byte, 32*1024)
+	var offset int64
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			if _, werr := w.WriteAt(buf[:n], offset); werr != nil {
+				return offset, werr
+			}
+			offset += int64(n)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return offset, err
+		}
+	}
+
+	return info.Size, nil
+}
+
+// readCloser pairs an arbitrary Reader with a Closer, used to return a
+// limited range of an open file while keeping the file's Close method.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func (l *localStorage) Put(bucket, key string, reader io.Reader, opts PutOptions) error {
+	path, err := l.objectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("could not create bucket directory: %v", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create object file: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, reader); err != nil {
+		return fmt.Errorf("could not write object file: %v", err)
+	}
+
+	return nil
+}
+
+func (l *localStorage) Delete(bucket, key string) error {
+	path, err := l.objectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (l *localStorage) DeleteMany(bucket string, keys []string) error {
+	for _, key := range keys {
+		if err := l.Delete(bucket, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *localStorage) List(ctx context.Context, bucket string) ([]s3Data, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	dir, err := l.bucketDir(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []s3Data{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	objects := []s3Data{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, s3Data{Key: entry.Name(), Size: info.Size()})
+	}
+
+	return objects, nil
+}
+
+// detectFileContentType sniffs the content type of an open file from its
+// first 512 bytes, then rewinds it so later reads see the whole file.
+func detectFileContentType(file *os.File) (string, error) {
+	buf := make([]byte, 512)
+	n, err := file.Read(buf)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return http.DetectContentType(buf[:n]), nil
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// s3Storage is the Storage implementation backed by an AWS S3 (or
+// S3-compatible, e.g. MinIO) bucket.
+type s3Storage struct {
+	client     *s3.S3
+	session    *session.Session
+	uploader   *s3manager.Uploader
+	downloader *s3manager.Downloader
+}
+
+func newS3Storage() *s3Storage {
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+
+	uploader := s3manager.NewUploader(sess)
+	if partSize, err := strconv.ParseInt(os.Getenv("S3_UPLOAD_PART_SIZE"), 10, 64); err == nil && partSize >= s3manager.MinUploadPartSize {
+		uploader.PartSize = partSize
+	}
+	if concurrency, err := strconv.Atoi(os.Getenv("S3_UPLOAD_CONCURRENCY")); err == nil && concurrency > 0 {
+		uploader.Concurrency = concurrency
+	}
+
+	downloader := s3manager.NewDownloader(sess)
+	if partSize, err := strconv.ParseInt(os.Getenv("S3_DOWNLOAD_PART_SIZE"), 10, 64); err == nil && partSize > 0 {
+		downloader.PartSize = partSize
+	}
+	if concurrency, err := strconv.Atoi(os.Getenv("S3_DOWNLOAD_CONCURRENCY")); err == nil && concurrency > 0 {
+		downloader.Concurrency = concurrency
+	}
+
+	return &s3Storage{
+		client:     s3.New(sess),
+		session:    sess,
+		uploader:   uploader,
+		downloader: downloader,
+	}
+}
+
+func (s *s3Storage) Get(bucket, key, rangeHeader string) (io.ReadCloser, ObjectInfo, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if rangeHeader != "" {
+		input.Range = aws.String(rangeHeader)
+	}
+
+	out, err := s.client.GetObject(input)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	return out.Body, objectInfoFromHead(aws.StringValue(out.ContentType), aws.Int64Value(out.ContentLength), out.LastModified, out.ETag), nil
+}
+
+func (s *s3Storage) Head(bucket, key string) (ObjectInfo, error) {
+	out, err := s.client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	return objectInfoFromHead(aws.StringValue(out.ContentType), aws.Int64Value(out.ContentLength), out.LastModified, out.ETag), nil
+}
+
+func objectInfoFromHead(contentType string, size int64, lastModified *time.Time, etag *string) ObjectInfo {
+	info := ObjectInfo{ContentType: contentType, Size: size, ETag: aws.StringValue(etag)}
+	if lastModified != nil {
+		info.LastModified = *lastModified
+	}
+
+	return info
+}
+
+// GetWriterAt fetches bucket/key using the S3 transfer manager, which splits
+// large objects into parts and downloads them concurrently.
+func (s *s3Storage) GetWriterAt(bucket, key string, w io.WriterAt) (int64, error) {
+	return s.downloader.Download(w, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+}
+
+func (s *s3Storage) Put(bucket, key string, reader io.Reader, opts PutOptions) error {
+	input := &s3manager.UploadInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        reader,
+		ContentType: aws.String(opts.ContentType),
+	}
+
+	if len(opts.Metadata) > 0 {
+		input.Metadata = aws.StringMap(opts.Metadata)
+	}
+	if opts.ServerSideEncryption != "" {
+		input.ServerSideEncryption = aws.String(opts.ServerSideEncryption)
+	}
+	if opts.SSEKMSKeyId != "" {
+		input.SSEKMSKeyId = aws.String(opts.SSEKMSKeyId)
+	}
+	if opts.StorageClass != "" {
+		input.StorageClass = aws.String(opts.StorageClass)
+	}
+	if opts.CacheControl != "" {
+		input.CacheControl = aws.String(opts.CacheControl)
+	}
+
+	_, err := s.uploader.Upload(input)
+
+	return err
+}
+
+// updateMetadata applies metadata to an already-uploaded object via a
+// server-side copy to itself, so callers can attach metadata (such as a
+// checksum) that was only known once the upload had finished streaming,
+// without re-transferring the object body. Since MetadataDirective=REPLACE
+// discards every system property not explicitly set on the copy, opts must
+// be the same options the original Put used, so content type, encryption,
+// storage class, and cache control all survive the copy unchanged.
+func (s *s3Storage) updateMetadata(bucket, key string, metadata map[string]string, opts PutOptions) error {
+	input := &s3.CopyObjectInput{
+		Bucket:            aws.String(bucket),
+		Key:               aws.String(key),
+		CopySource:        aws.String(bucket + "/" + key),
+		ContentType:       aws.String(opts.ContentType),
+		Metadata:          aws.StringMap(metadata),
+		MetadataDirective: aws.String(s3.MetadataDirectiveReplace),
+	}
+
+	if opts.ServerSideEncryption != "" {
+		input.ServerSideEncryption = aws.String(opts.ServerSideEncryption)
+	}
+	if opts.SSEKMSKeyId != "" {
+		input.SSEKMSKeyId = aws.String(opts.SSEKMSKeyId)
+	}
+	if opts.StorageClass != "" {
+		input.StorageClass = aws.String(opts.StorageClass)
+	}
+	if opts.CacheControl != "" {
+		input.CacheControl = aws.String(opts.CacheControl)
+	}
+
+	_, err := s.client.CopyObject(input)
+
+	return err
+}
+
+func (s *s3Storage) List(ctx context.Context, bucket string) ([]s3Data, error) {
+	objects := []s3Data{}
+	err := s.client.ListObjectsPagesWithContext(
+		ctx,
+		&s3.ListObjectsInput{Bucket: aws.String(bucket)},
+		func(p *s3.ListObjectsOutput, lastPage bool) bool {
+			for _, o := range p.Contents {
+				objects = append(objects, s3Data{Key: aws.StringValue(o.Key), Size: aws.Int64Value(o.Size)})
+			}
+			return true
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+func (s *s3Storage) Delete(bucket, key string) error {
+	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+
+	return err
+}
+
+func (s *s3Storage) DeleteMany(bucket string, keys []string) error {
+	objects := make([]*s3.ObjectIdentifier, len(keys))
+	for i, key := range keys {
+		objects[i] = &s3.ObjectIdentifier{Key: aws.String(key)}
+	}
+
+	out, err := s.client.DeleteObjects(&s3.DeleteObjectsInput{
+		Bucket: aws.String(bucket),
+		Delete: &s3.Delete{Objects: objects},
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(out.Errors) > 0 {
+		return fmt.Errorf("failed to delete %d of %d objects: %s", len(out.Errors), len(keys), aws.StringValue(out.Errors[0].Message))
+	}
+
+	return nil
+}
+
+// postPolicyDocument is the JSON document a presigned POST policy signs, per
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-HTTPPOSTConstructPolicy.html.
+type postPolicyDocument struct {
+	Expiration string        `json:"expiration"`
+	Conditions []interface{} `json:"conditions"`
+}
+
+// presignPostPolicy builds the fields an HTML form needs to POST key
+// directly into bucket, bypassing the server entirely, the form-upload
+// equivalent of a presigned PUT URL. The policy expires after timeout.
+func (s *s3Storage) presignPostPolicy(bucket, key string, timeout time.Duration) (presignedPostPolicy, error) {
+	creds, err := s.session.Config.Credentials.Get()
+	if err != nil {
+		return presignedPostPolicy{}, fmt.Errorf("could not retrieve credentials: %v", err)
+	}
+
+	region := aws.StringValue(s.session.Config.Region)
+	now := time.Now().UTC()
+	date := now.Format("20060102")
+	amzDate := now.Format("20060102T150405Z")
+	credential := fmt.Sprintf("%s/%s/%s/s3/aws4_request", creds.AccessKeyID, date, region)
+
+	conditions := []interface{}{
+		map[string]string{"bucket": bucket},
+		[]string{"eq", "$key", key},
+		map[string]string{"x-amz-algorithm": "AWS4-HMAC-SHA256"},
+		map[string]string{"x-amz-credential": credential},
+		map[string]string{"x-amz-date": amzDate},
+	}
+	if creds.SessionToken != "" {
+		conditions = append(conditions, map[string]string{"x-amz-security-token": creds.SessionToken})
+	}
+
+	document, err := json.Marshal(postPolicyDocument{
+		Expiration: now.Add(timeout).Format(time.RFC3339),
+		Conditions: conditions,
+	})
+	if err != nil {
+		return presignedPostPolicy{}, err
+	}
+	policy := base64.StdEncoding.EncodeToString(document)
+
+	fields := map[string]string{
+		"key":              key,
+		"x-amz-algorithm":  "AWS4-HMAC-SHA256",
+		"x-amz-credential": credential,
+		"x-amz-date":       amzDate,
+		"policy":           policy,
+		"x-amz-signature":  hex.EncodeToString(postPolicySignature(creds.SecretAccessKey, date, region, policy)),
+	}
+	if creds.SessionToken != "" {
+		fields["x-amz-security-token"] = creds.SessionToken
+	}
+
+	return presignedPostPolicy{
+		URL:    fmt.Sprintf("%s/%s", strings.TrimSuffix(s.client.Endpoint, "/"), bucket),
+		Fields: fields,
+	}, nil
+}
+
+// postPolicySignature derives the SigV4 signing key for date/region/s3 and
+// uses it to sign stringToSign (the base64-encoded policy document).
+func postPolicySignature(secret, date, region, stringToSign string) []byte {
+	hmacSHA256 := func(key []byte, data string) []byte {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(data))
+		return mac.Sum(nil)
+	}
+
+	kDate := hmacSHA256([]byte("AWS4"+secret), date)
+	kRegion := hmacSHA256(kDate, region)
+	kService := hmacSHA256(kRegion, "s3")
+	kSigning := hmacSHA256(kService, "aws4_request")
+
+	return hmacSHA256(kSigning, stringToSign)
+}